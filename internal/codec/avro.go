@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"context"
+	"io"
+
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// avroOCFReader decodes an Avro Object Container File into one message part
+// per record, each shaped as a JSON object. Blocks are decoded incrementally
+// by the underlying goavro.OCFReader as records are scanned, rather than all
+// at once up front.
+func avroOCFReader(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	codec, err := goavro.NewOCFReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &avroOCFReaderType{
+		r:       r,
+		codec:   codec,
+		pending: newPendingAcks(ackFn),
+	}, nil
+}
+
+type avroOCFReaderType struct {
+	r          io.ReadCloser
+	codec      *goavro.OCFReader
+	pending    *pendingAcks
+	closed     bool
+	reachedEOF bool
+}
+
+func (a *avroOCFReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	if !a.codec.Scan() {
+		a.reachedEOF = true
+		if err := a.codec.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+
+	datum, err := a.codec.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	part := message.NewPart(nil)
+	if err := part.SetJSON(datum); err != nil {
+		return nil, nil, err
+	}
+
+	return []types.Part{part}, a.pending.Add(), nil
+}
+
+func (a *avroOCFReaderType) Close(ctx context.Context) error {
+	if !a.closed {
+		a.closed = true
+		closeErr := errServiceShuttingDown
+		if a.reachedEOF {
+			closeErr = nil
+		}
+		_ = a.pending.Done(ctx, closeErr)
+	}
+	return a.r.Close()
+}
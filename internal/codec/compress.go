@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+// zstdWrap decorates an inner reader constructor so that the stream it
+// consumes is first decompressed with zstd, mirroring gzipWrap.
+func zstdWrap(inner ReaderConstructor) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		// Force a single decoder goroutine so reads stay pull-based, same
+		// as every other wrap in this package: without this zstd's default
+		// concurrent decoder starts reading r in the background as soon as
+		// it resets, racing with our own calls into r.
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		rdr, err := inner(path, readCloserWrap{zstdDecoderCloser{dec}, r}, ackFn)
+		if err != nil {
+			dec.Close()
+			r.Close()
+			return nil, err
+		}
+		return rdr, nil
+	}
+}
+
+// zstdDecoderCloser adapts *zstd.Decoder (whose Close method returns nothing)
+// to io.Closer so that readCloserWrap reclaims the decoder's background
+// goroutines when the wrapped reader is closed.
+type zstdDecoderCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdDecoderCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// lz4Wrap decorates an inner reader constructor so that the stream it
+// consumes is first decompressed with lz4, mirroring gzipWrap.
+func lz4Wrap(inner ReaderConstructor) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		rdr, err := inner(path, readCloserWrap{lz4.NewReader(r), r}, ackFn)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return rdr, nil
+	}
+}
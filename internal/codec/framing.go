@@ -0,0 +1,283 @@
+package codec
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// defaultMaxFrameSize is used by the length-prefixed and netstring codecs
+// when no explicit max_frame_size option is supplied, guarding against a
+// corrupt or malicious length header causing an unbounded allocation.
+const defaultMaxFrameSize = 128 * 1024 * 1024
+
+// regexReader splits a stream into tokens on matches of a user supplied
+// regular expression, similar to delimReader but pattern based rather than
+// literal. This is useful for formats such as log files where each entry
+// begins with a timestamp.
+func regexReader(conf ReaderConfig, pattern string) (ReaderConstructor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex codec pattern: %w", err)
+	}
+
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		scanner := bufio.NewScanner(r)
+		if conf.MaxScanTokenSize != bufio.MaxScanTokenSize {
+			scanner.Buffer(nil, conf.MaxScanTokenSize)
+		}
+		scanner.Split(regexSplitFunc(re))
+		return &scannerReader{r: r, scanner: scanner, pending: newPendingAcks(ackFn)}, nil
+	}, nil
+}
+
+// regexSplitFunc treats each regex match as the start of a new record, so a
+// token runs from one match up to (but not including) the next. This suits
+// formats like log files where every entry begins with a timestamp.
+func regexSplitFunc(re *regexp.Regexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		first := re.FindIndex(data)
+		if first == nil {
+			if atEOF {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		}
+
+		// Bytes preceding the first match are a record with no leading
+		// delimiter (a preamble, or an entry whose delimiter was consumed
+		// in an earlier buffer fill). Emit them on their own so progress is
+		// always made without losing data.
+		if first[0] > 0 {
+			return first[0], data[:first[0]], nil
+		}
+
+		second := re.FindIndex(data[first[1]:])
+		if second == nil {
+			if atEOF {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		}
+
+		end := first[1] + second[0]
+		return end, data[:end], nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type lengthEncoding int
+
+const (
+	lengthEncodingVarint lengthEncoding = iota
+	lengthEncodingBE32
+	lengthEncodingLE32
+)
+
+// parseLengthPrefixedCodec splits the "<encoding>[,max_frame_size=N]" options
+// that follow the "length-prefixed:" prefix of a codec string into the
+// encoding name and optional frame size limit.
+func parseLengthPrefixedCodec(opts string) (encStr string, maxFrameSize int, err error) {
+	parts := strings.Split(opts, ",")
+	encStr = parts[0]
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 || kv[0] != "max_frame_size" {
+			return "", 0, fmt.Errorf("invalid length-prefixed codec option: %v", opt)
+		}
+		if maxFrameSize, err = strconv.Atoi(kv[1]); err != nil {
+			return "", 0, fmt.Errorf("invalid max_frame_size: %w", err)
+		}
+	}
+	return encStr, maxFrameSize, nil
+}
+
+// lengthPrefixedReader parses a stream of length-prefixed frames, where each
+// frame is a header (a varint, or a fixed 4-byte big/little-endian uint32)
+// giving the size of the payload that immediately follows it.
+func lengthPrefixedReader(encStr string, maxFrameSize int) (ReaderConstructor, error) {
+	var enc lengthEncoding
+	switch encStr {
+	case "varint":
+		enc = lengthEncodingVarint
+	case "be32":
+		enc = lengthEncodingBE32
+	case "le32":
+		enc = lengthEncodingLE32
+	default:
+		return nil, fmt.Errorf("unrecognised length-prefixed encoding: %v", encStr)
+	}
+
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		return &lengthPrefixedReaderType{
+			r:            bufio.NewReader(r),
+			underlying:   r,
+			enc:          enc,
+			maxFrameSize: maxFrameSize,
+			pending:      newPendingAcks(ackFn),
+		}, nil
+	}, nil
+}
+
+type lengthPrefixedReaderType struct {
+	r            *bufio.Reader
+	underlying   io.ReadCloser
+	enc          lengthEncoding
+	maxFrameSize int
+	pending      *pendingAcks
+	closed       bool
+	reachedEOF   bool
+}
+
+func (l *lengthPrefixedReaderType) readHeader() (int, error) {
+	switch l.enc {
+	case lengthEncodingVarint:
+		n, err := binary.ReadUvarint(l.r)
+		return int(n), err
+	case lengthEncodingBE32:
+		var buf [4]byte
+		if _, err := io.ReadFull(l.r, buf[:]); err != nil {
+			return 0, err
+		}
+		return int(binary.BigEndian.Uint32(buf[:])), nil
+	default: // lengthEncodingLE32
+		var buf [4]byte
+		if _, err := io.ReadFull(l.r, buf[:]); err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint32(buf[:])), nil
+	}
+}
+
+func (l *lengthPrefixedReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	size, err := l.readHeader()
+	if err != nil {
+		l.reachedEOF = true
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, err
+	}
+
+	if size > l.maxFrameSize {
+		l.reachedEOF = true
+		return nil, nil, fmt.Errorf("length-prefixed frame of %v bytes exceeds max_frame_size of %v bytes", size, l.maxFrameSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(l.r, data); err != nil {
+		l.reachedEOF = true
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+
+	part := message.NewPart(data)
+	return []types.Part{part}, l.pending.Add(), nil
+}
+
+func (l *lengthPrefixedReaderType) Close(ctx context.Context) error {
+	if !l.closed {
+		l.closed = true
+		closeErr := errServiceShuttingDown
+		if l.reachedEOF {
+			closeErr = nil
+		}
+		_ = l.pending.Done(ctx, closeErr)
+	}
+	return l.underlying.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// netstringReader parses the classic djb netstring framing: an ASCII decimal
+// length, a colon, that many payload bytes, and a trailing comma.
+func netstringReader(maxFrameSize int) ReaderConstructor {
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		return &netstringReaderType{
+			r:            bufio.NewReader(r),
+			underlying:   r,
+			maxFrameSize: maxFrameSize,
+			pending:      newPendingAcks(ackFn),
+		}, nil
+	}
+}
+
+type netstringReaderType struct {
+	r            *bufio.Reader
+	underlying   io.ReadCloser
+	maxFrameSize int
+	pending      *pendingAcks
+	closed       bool
+	reachedEOF   bool
+}
+
+func (n *netstringReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	lenStr, err := n.r.ReadString(':')
+	if err != nil {
+		n.reachedEOF = true
+		if err == io.EOF && lenStr == "" {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	lenStr = strings.TrimSuffix(lenStr, ":")
+
+	size, err := strconv.Atoi(lenStr)
+	if err != nil || size < 0 {
+		n.reachedEOF = true
+		return nil, nil, fmt.Errorf("invalid netstring frame length: %v", lenStr)
+	}
+	if size > n.maxFrameSize {
+		n.reachedEOF = true
+		return nil, nil, fmt.Errorf("netstring frame of %v bytes exceeds max_frame_size of %v bytes", size, n.maxFrameSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(n.r, data); err != nil {
+		n.reachedEOF = true
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	comma, err := n.r.ReadByte()
+	if err != nil || comma != ',' {
+		n.reachedEOF = true
+		return nil, nil, fmt.Errorf("netstring frame missing trailing comma")
+	}
+
+	part := message.NewPart(data)
+	return []types.Part{part}, n.pending.Add(), nil
+}
+
+func (n *netstringReaderType) Close(ctx context.Context) error {
+	if !n.closed {
+		n.closed = true
+		closeErr := errServiceShuttingDown
+		if n.reachedEOF {
+			closeErr = nil
+		}
+		_ = n.pending.Done(ctx, closeErr)
+	}
+	return n.underlying.Close()
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// jsonArrayReader treats the stream as a single top-level JSON array and
+// streams each element out as its own message part using a json.Decoder, so
+// that a multi-gigabyte array never has to be held in memory all at once.
+// Each element is decoded as a json.RawMessage, so nested objects and arrays
+// are carried through untouched regardless of how deep they go.
+func jsonArrayReader(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to read opening json-array token: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		r.Close()
+		return nil, fmt.Errorf("json-array codec expects the stream to begin with '[', got: %v", tok)
+	}
+
+	return &jsonArrayReaderType{r: r, dec: dec, pending: newPendingAcks(ackFn)}, nil
+}
+
+type jsonArrayReaderType struct {
+	r          io.ReadCloser
+	dec        *json.Decoder
+	pending    *pendingAcks
+	closed     bool
+	reachedEOF bool
+}
+
+func (j *jsonArrayReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	if !j.dec.More() {
+		j.reachedEOF = true
+		// Consume the closing ']' so a reader that outlives us (unlikely,
+		// but cheap to guard against) doesn't choke on it.
+		_, _ = j.dec.Token()
+		return nil, nil, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	part := message.NewPart(raw)
+	return []types.Part{part}, j.pending.Add(), nil
+}
+
+func (j *jsonArrayReaderType) Close(ctx context.Context) error {
+	if !j.closed {
+		j.closed = true
+		closeErr := errServiceShuttingDown
+		if j.reachedEOF {
+			closeErr = nil
+		}
+		_ = j.pending.Done(ctx, closeErr)
+	}
+	return j.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// schemaCache holds compiled JSON schemas keyed by their source URL so that
+// a schema referenced by multiple jsonl-schema codec instances (e.g. one per
+// input shard) is only ever compiled once.
+var schemaCache sync.Map // map[string]*jsonschema.Schema
+
+func compiledSchema(url string) (*jsonschema.Schema, error) {
+	if s, ok := schemaCache.Load(url); ok {
+		return s.(*jsonschema.Schema), nil
+	}
+
+	schema, err := jsonschema.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile json schema %v: %w", url, err)
+	}
+
+	actual, _ := schemaCache.LoadOrStore(url, schema)
+	return actual.(*jsonschema.Schema), nil
+}
+
+// jsonlSchemaValidationErrorMetaKey is set on a part's metadata when the
+// line fails JSON decoding or schema validation, so that a downstream
+// "catch" block can route it away from the happy path instead of it being
+// silently dropped.
+const jsonlSchemaValidationErrorMetaKey = "jsonl_schema_validation_error"
+
+// jsonlSchemaReader wraps the plain "lines" codec so that each line is
+// validated against a JSON Schema, flagging (rather than dropping) any line
+// that fails validation by setting jsonlSchemaValidationErrorMetaKey.
+func jsonlSchemaReader(conf ReaderConfig, url string) (ReaderConstructor, error) {
+	schema, err := compiledSchema(url)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := linesReader(conf)
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		inner, err := lines(path, r, ackFn)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonlSchemaReaderType{inner: inner, schema: schema}, nil
+	}, nil
+}
+
+type jsonlSchemaReaderType struct {
+	inner  Reader
+	schema *jsonschema.Schema
+}
+
+func (j *jsonlSchemaReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	p, ackFn, err := j.inner.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(p[0].Get(), &v); err != nil {
+		p[0].Metadata().Set(jsonlSchemaValidationErrorMetaKey, fmt.Sprintf("line is not valid json: %v", err))
+		return p, ackFn, nil
+	}
+	if err := j.schema.Validate(v); err != nil {
+		p[0].Metadata().Set(jsonlSchemaValidationErrorMetaKey, fmt.Sprintf("line failed schema validation: %v", err))
+		return p, ackFn, nil
+	}
+
+	return p, ackFn, nil
+}
+
+func (j *jsonlSchemaReaderType) Close(ctx context.Context) error {
+	return j.inner.Close(ctx)
+}
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/segmentio/parquet-go"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// maxParquetBufferBytes is the largest parquet file that will be buffered
+// fully in memory before spilling over to a temporary file. A parquet
+// file's footer, which holds the schema and row group offsets needed to
+// read anything at all, lives at the very end of the file, so the whole
+// stream must be available through an io.ReaderAt up front.
+const maxParquetBufferBytes = 64 * 1024 * 1024
+
+// parquetReader decodes a parquet file into one message part per row, each
+// shaped as a JSON object keyed by column name. Rows are pulled one at a
+// time from the underlying parquet.Reader, which in turn reads row groups
+// lazily, so memory stays bounded regardless of file size.
+func parquetReader(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	ra, size, cleanup, err := bufferToReaderAt(r, maxParquetBufferBytes)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	pf, err := parquet.OpenFile(ra, size)
+	if err != nil {
+		cleanup()
+		r.Close()
+		return nil, err
+	}
+
+	columns := make([]string, len(pf.Schema().Columns()))
+	for i, path := range pf.Schema().Columns() {
+		columns[i] = strings.Join(path, ".")
+	}
+
+	return &parquetReaderType{
+		r:       r,
+		cleanup: cleanup,
+		pr:      parquet.NewReader(pf),
+		columns: columns,
+		pending: newPendingAcks(ackFn),
+	}, nil
+}
+
+type parquetReaderType struct {
+	r          io.ReadCloser
+	cleanup    func() error
+	pr         *parquet.Reader
+	columns    []string
+	pending    *pendingAcks
+	closed     bool
+	reachedEOF bool
+}
+
+func (p *parquetReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	rows := make([]parquet.Row, 1)
+	n, err := p.pr.ReadRows(rows)
+	if n == 0 {
+		p.reachedEOF = true
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+
+	obj := make(map[string]interface{}, len(rows[0]))
+	for _, v := range rows[0] {
+		if v.IsNull() {
+			continue
+		}
+		obj[p.columns[v.Column()]] = parquetValue(v)
+	}
+
+	part := message.NewPart(nil)
+	if jErr := part.SetJSON(obj); jErr != nil {
+		return nil, nil, jErr
+	}
+
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return []types.Part{part}, p.pending.Add(), nil
+}
+
+// parquetValue converts a parquet.Value into its closest JSON-shaped
+// equivalent by its physical kind, falling back to its string representation
+// for kinds without a JSON scalar counterpart (e.g. INT96). Logical types are
+// not interpreted: a decimal comes out as its unscaled integer or raw bytes.
+func parquetValue(v parquet.Value) interface{} {
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return v.Int32()
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Float:
+		return v.Float()
+	case parquet.Double:
+		return v.Double()
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray())
+	default:
+		return v.String()
+	}
+}
+
+func (p *parquetReaderType) Close(ctx context.Context) error {
+	if !p.closed {
+		p.closed = true
+		closeErr := errServiceShuttingDown
+		if p.reachedEOF {
+			closeErr = nil
+		}
+		_ = p.pending.Done(ctx, closeErr)
+	}
+	rErr := p.r.Close()
+	if cErr := p.cleanup(); cErr != nil && rErr == nil {
+		rErr = cErr
+	}
+	return rErr
+}
@@ -0,0 +1,674 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// ReaderConfig describes general configuration options that apply to codec
+// readers.
+type ReaderConfig struct {
+	MaxScanTokenSize int
+}
+
+// NewReaderConfig creates a new reader config with default values.
+func NewReaderConfig() ReaderConfig {
+	return ReaderConfig{
+		MaxScanTokenSize: bufio.MaxScanTokenSize,
+	}
+}
+
+// ReaderAckFn is a function provided to a reader that should be called once
+// the underlying data it has returned is no longer needed, either because it
+// has been successfully delivered or because it has failed and will not be
+// retried. The provided error indicates whether delivery was successful, and
+// is nil otherwise.
+type ReaderAckFn func(ctx context.Context, err error) error
+
+// Reader is a codec type that reads message parts from an underlying stream
+// of bytes, supporting both single and multiple part messages per call to
+// Next.
+type Reader interface {
+	// Next returns the next batch of parts to be consumed from the
+	// underlying stream, along with an ack function to be called once the
+	// parts are no longer needed.
+	Next(ctx context.Context) ([]types.Part, ReaderAckFn, error)
+
+	// Close the underlying stream.
+	Close(ctx context.Context) error
+}
+
+// ReaderConstructor is a constructor for creating a Reader from a path
+// (which may be empty) and a stream of bytes. The ackFn provided is called
+// once all parts read from the stream have been acknowledged or nacked, with
+// an error when at least one of those acks was a failure.
+type ReaderConstructor func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error)
+
+//------------------------------------------------------------------------------
+
+// GetReader returns a constructor for a given codec string, or an error if
+// the codec is unrecognised.
+func GetReader(codec string, conf ReaderConfig) (ReaderConstructor, error) {
+	codec, multipart := stripMultipart(codec)
+
+	ctor, err := getReader(codec, conf)
+	if err != nil {
+		return nil, err
+	}
+	if !multipart {
+		return ctor, nil
+	}
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		return newMultipartReader(ctor, path, r, ackFn)
+	}, nil
+}
+
+func stripMultipart(codec string) (string, bool) {
+	if rest := strings.TrimSuffix(codec, "/multipart"); rest != codec {
+		return rest, true
+	}
+	return codec, false
+}
+
+func getReader(codec string, conf ReaderConfig) (ReaderConstructor, error) {
+	switch {
+	case codec == "all-bytes":
+		return allBytesReader, nil
+	case codec == "lines":
+		return linesReader(conf), nil
+	case strings.HasPrefix(codec, "delim:"):
+		delim := strings.TrimPrefix(codec, "delim:")
+		if delim == "" {
+			return nil, fmt.Errorf("delim codec requires a non-empty delimiter")
+		}
+		return delimReader(conf, delim), nil
+	case strings.HasPrefix(codec, "chunker:"):
+		chunkStr := strings.TrimPrefix(codec, "chunker:")
+		chunkSize, err := strconv.Atoi(chunkStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size for chunker codec: %w", err)
+		}
+		if chunkSize <= 0 {
+			return nil, fmt.Errorf("chunk size for chunker codec must be greater than zero")
+		}
+		return chunkerReader(chunkSize), nil
+	case codec == "csv" || strings.HasPrefix(codec, "csv:"):
+		delim := ','
+		if split := strings.SplitN(codec, ":", 2); len(split) == 2 {
+			if len(split[1]) != 1 {
+				return nil, fmt.Errorf("csv delimiter must be a single character, got: %v", split[1])
+			}
+			delim = rune(split[1][0])
+		}
+		return csvReader(conf, delim), nil
+	case codec == "csv-gzip":
+		inner := csvReader(conf, ',')
+		return gzipWrap(inner), nil
+	case codec == "tar" || codec == "tar:with_headers":
+		return tarReaderCtor(strings.HasSuffix(codec, ":with_headers")), nil
+	case codec == "tar-gzip" || codec == "gzip/tar" || codec == "tar-gzip:with_headers" || codec == "gzip/tar:with_headers":
+		return gzipWrap(tarReaderCtor(strings.HasSuffix(codec, ":with_headers"))), nil
+	case codec == "zip" || strings.HasPrefix(codec, "zip:") || strings.HasPrefix(codec, "zip/"):
+		return zipCodec(conf, codec)
+	case strings.HasPrefix(codec, "regex:"):
+		return regexReader(conf, strings.TrimPrefix(codec, "regex:"))
+	case codec == "netstring" || strings.HasPrefix(codec, "netstring:"):
+		maxFrameSize := 0
+		if opt := strings.TrimPrefix(codec, "netstring:"); opt != codec {
+			var err error
+			if _, maxFrameSize, err = parseLengthPrefixedCodec("netstring," + opt); err != nil {
+				return nil, err
+			}
+		}
+		return netstringReader(maxFrameSize), nil
+	case strings.HasPrefix(codec, "length-prefixed:"):
+		encStr, maxFrameSize, err := parseLengthPrefixedCodec(strings.TrimPrefix(codec, "length-prefixed:"))
+		if err != nil {
+			return nil, err
+		}
+		return lengthPrefixedReader(encStr, maxFrameSize)
+	case codec == "json-array":
+		return jsonArrayReader, nil
+	case strings.HasPrefix(codec, "jsonl-schema:"):
+		return jsonlSchemaReader(conf, strings.TrimPrefix(codec, "jsonl-schema:"))
+	case strings.HasPrefix(codec, "gzip/"):
+		inner, err := getReader(strings.TrimPrefix(codec, "gzip/"), conf)
+		if err != nil {
+			return nil, err
+		}
+		return gzipWrap(inner), nil
+	case strings.HasPrefix(codec, "zstd/"):
+		inner, err := getReader(strings.TrimPrefix(codec, "zstd/"), conf)
+		if err != nil {
+			return nil, err
+		}
+		return zstdWrap(inner), nil
+	case strings.HasPrefix(codec, "lz4/"):
+		inner, err := getReader(strings.TrimPrefix(codec, "lz4/"), conf)
+		if err != nil {
+			return nil, err
+		}
+		return lz4Wrap(inner), nil
+	case codec == "parquet":
+		return parquetReader, nil
+	case codec == "avro-ocf":
+		return avroOCFReader, nil
+	case codec == "auto":
+		return autoReader(conf), nil
+	}
+	return nil, fmt.Errorf("codec was not recognised: %v", codec)
+}
+
+//------------------------------------------------------------------------------
+
+// autoReader inspects a file path (when present) and picks an appropriate
+// codec based on its extension, falling back to "lines" when nothing more
+// specific matches.
+func autoReader(conf ReaderConfig) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		codec := "lines"
+		lower := strings.ToLower(path)
+		switch {
+		case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tar.gzip"), strings.HasSuffix(lower, ".tgz"):
+			codec = "tar-gzip"
+		case strings.HasSuffix(lower, ".tar"):
+			codec = "tar"
+		case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".gzip"):
+			codec = "gzip/lines"
+		case strings.HasSuffix(lower, ".csv"):
+			codec = "csv"
+		case strings.HasSuffix(lower, ".zip"):
+			codec = "zip"
+		case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+			codec = "zstd/tar"
+		case strings.HasSuffix(lower, ".zst"), strings.HasSuffix(lower, ".zstd"):
+			codec = "zstd/lines"
+		case strings.HasSuffix(lower, ".lz4"):
+			codec = "lz4/lines"
+		case strings.HasSuffix(lower, ".json"):
+			codec = "json-array"
+		case strings.HasSuffix(lower, ".ndjson"), strings.HasSuffix(lower, ".jsonl"):
+			codec = "lines"
+		case strings.HasSuffix(lower, ".parquet"):
+			codec = "parquet"
+		case strings.HasSuffix(lower, ".avro"):
+			codec = "avro-ocf"
+		}
+		ctor, err := getReader(codec, conf)
+		if err != nil {
+			return nil, err
+		}
+		return ctor(path, r, ackFn)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// bufferToReaderAt buffers a stream into something addressable via
+// io.ReaderAt, as required by formats such as zip and parquet whose
+// structural metadata lives at the end of the file. Streams up to
+// maxBufferBytes are held entirely in memory; anything larger spills over to
+// a temporary file. The returned cleanup func must be called once the
+// io.ReaderAt is no longer needed.
+func bufferToReaderAt(r io.Reader, maxBufferBytes int) (io.ReaderAt, int64, func() error, error) {
+	limited := io.LimitReader(r, int64(maxBufferBytes)+1)
+	buf, err := readAll(limited)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if len(buf) <= maxBufferBytes {
+		return bytes.NewReader(buf), int64(len(buf)), func() error { return nil }, nil
+	}
+
+	tmp, err := ioutil.TempFile("", "benthos-codec-")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() error {
+		closeErr := tmp.Close()
+		if rmErr := os.Remove(tmp.Name()); closeErr == nil {
+			closeErr = rmErr
+		}
+		return closeErr
+	}
+
+	if _, err := tmp.Write(buf); err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return tmp, info.Size(), cleanup, nil
+}
+
+func gzipWrap(inner ReaderConstructor) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		rdr, err := inner(path, readCloserWrap{gzipReader, r}, ackFn)
+		if err != nil {
+			gzipReader.Close()
+			r.Close()
+			return nil, err
+		}
+		return rdr, nil
+	}
+}
+
+// readCloserWrap pairs an io.Reader (the decompressed stream) with an
+// underlying io.Closer (the raw stream) so that closing the reader closes
+// both layers.
+type readCloserWrap struct {
+	io.Reader
+	underlying io.Closer
+}
+
+func (r readCloserWrap) Close() error {
+	if closer, ok := r.Reader.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			r.underlying.Close()
+			return err
+		}
+	}
+	return r.underlying.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// pendingAcks tracks acks for every part read from a stream over its entire
+// lifetime and calls the outer ackFn exactly once, as soon as the stream is
+// both exhausted (Done has been called) and every part handed out has been
+// individually acked or nacked. The first non-nil error encountered, from
+// either an ack or Done itself, is what's surfaced.
+type pendingAcks struct {
+	mut      sync.Mutex
+	ackFn    ReaderAckFn
+	total    int
+	pending  int
+	done     bool
+	fired    bool
+	finalErr error
+}
+
+func newPendingAcks(ackFn ReaderAckFn) *pendingAcks {
+	return &pendingAcks{ackFn: ackFn}
+}
+
+func (p *pendingAcks) Add() ReaderAckFn {
+	p.mut.Lock()
+	p.total++
+	p.pending++
+	p.mut.Unlock()
+
+	return func(ctx context.Context, err error) error {
+		p.mut.Lock()
+		p.pending--
+		if err != nil && p.finalErr == nil {
+			p.finalErr = err
+		}
+		fire := p.done && p.pending == 0 && !p.fired
+		p.fired = p.fired || fire
+		finalErr := p.finalErr
+		p.mut.Unlock()
+
+		if fire {
+			return p.ackFn(ctx, finalErr)
+		}
+		return nil
+	}
+}
+
+// Done marks the stream as exhausted, either because it was read to
+// completion or because it was closed early. A non-nil error is used as the
+// final result unless an earlier ack already failed.
+func (p *pendingAcks) Done(ctx context.Context, err error) error {
+	p.mut.Lock()
+	p.done = true
+	if err != nil && p.finalErr == nil {
+		p.finalErr = err
+	}
+	fire := p.pending == 0 && !p.fired
+	p.fired = p.fired || fire
+	finalErr := p.finalErr
+	p.mut.Unlock()
+
+	if fire {
+		return p.ackFn(ctx, finalErr)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func allBytesReader(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	return &allBytesReaderType{r: r, ackFn: ackFn}, nil
+}
+
+type allBytesReaderType struct {
+	r     io.ReadCloser
+	ackFn ReaderAckFn
+	done  bool
+}
+
+func (a *allBytesReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	if a.done {
+		return nil, nil, io.EOF
+	}
+	a.done = true
+
+	b, err := readAll(a.r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	part := message.NewPart(b)
+	return []types.Part{part}, singleAck(a.ackFn), nil
+}
+
+func (a *allBytesReaderType) Close(ctx context.Context) error {
+	if !a.done {
+		_ = a.ackFn(ctx, errServiceShuttingDown)
+	}
+	return a.r.Close()
+}
+
+func readAll(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, r)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func singleAck(ackFn ReaderAckFn) ReaderAckFn {
+	return func(ctx context.Context, err error) error {
+		return ackFn(ctx, err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func linesReader(conf ReaderConfig) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		scanner := bufio.NewScanner(r)
+		if conf.MaxScanTokenSize != bufio.MaxScanTokenSize {
+			scanner.Buffer(nil, conf.MaxScanTokenSize)
+		}
+		return &scannerReader{r: r, scanner: scanner, pending: newPendingAcks(ackFn)}, nil
+	}
+}
+
+func delimReader(conf ReaderConfig, delim string) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		scanner := bufio.NewScanner(r)
+		if conf.MaxScanTokenSize != bufio.MaxScanTokenSize {
+			scanner.Buffer(nil, conf.MaxScanTokenSize)
+		}
+		scanner.Split(delimSplitFunc([]byte(delim)))
+		return &scannerReader{r: r, scanner: scanner, pending: newPendingAcks(ackFn)}, nil
+	}
+}
+
+func delimSplitFunc(delim []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delim); i >= 0 {
+			return i + len(delim), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// scannerReader is a Reader implementation backed by a bufio.Scanner,
+// emitting one message part per scanned token.
+type scannerReader struct {
+	r          io.ReadCloser
+	scanner    *bufio.Scanner
+	pending    *pendingAcks
+	closed     bool
+	reachedEOF bool
+}
+
+func (s *scannerReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	if !s.scanner.Scan() {
+		s.reachedEOF = true
+		if err := s.scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+
+	data := make([]byte, len(s.scanner.Bytes()))
+	copy(data, s.scanner.Bytes())
+
+	part := message.NewPart(data)
+	return []types.Part{part}, s.pending.Add(), nil
+}
+
+func (s *scannerReader) Close(ctx context.Context) error {
+	if !s.closed {
+		s.closed = true
+		closeErr := errServiceShuttingDown
+		if s.reachedEOF {
+			closeErr = nil
+		}
+		_ = s.pending.Done(ctx, closeErr)
+	}
+	return s.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
+func chunkerReader(size int) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		return &chunkerReaderType{r: r, size: size, pending: newPendingAcks(ackFn)}, nil
+	}
+}
+
+type chunkerReaderType struct {
+	r          io.ReadCloser
+	size       int
+	pending    *pendingAcks
+	closed     bool
+	reachedEOF bool
+}
+
+func (c *chunkerReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	buf := make([]byte, c.size)
+	n, err := io.ReadFull(c.r, buf)
+	if n == 0 {
+		c.reachedEOF = true
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, nil, io.EOF
+			}
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+
+	part := message.NewPart(buf[:n])
+	ackFn := c.pending.Add()
+
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	return []types.Part{part}, ackFn, nil
+}
+
+func (c *chunkerReaderType) Close(ctx context.Context) error {
+	if !c.closed {
+		c.closed = true
+		closeErr := errServiceShuttingDown
+		if c.reachedEOF {
+			closeErr = nil
+		}
+		_ = c.pending.Done(ctx, closeErr)
+	}
+	return c.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
+func csvReader(conf ReaderConfig, delim rune) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		csvR := csv.NewReader(r)
+		csvR.Comma = delim
+		csvR.ReuseRecord = false
+
+		headers, err := csvR.Read()
+		if err != nil {
+			if err == io.EOF {
+				return &csvReaderType{r: r, pending: newPendingAcks(ackFn), done: true}, nil
+			}
+			r.Close()
+			return nil, err
+		}
+
+		return &csvReaderType{r: r, csvR: csvR, headers: headers, pending: newPendingAcks(ackFn)}, nil
+	}
+}
+
+type csvReaderType struct {
+	r       io.ReadCloser
+	csvR    *csv.Reader
+	headers []string
+	pending *pendingAcks
+	done    bool
+	closed  bool
+}
+
+func (c *csvReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	if c.done {
+		return nil, nil, io.EOF
+	}
+
+	record, err := c.csvR.Read()
+	if err != nil {
+		c.done = true
+		return nil, nil, err
+	}
+
+	obj := make(map[string]interface{}, len(c.headers))
+	for i, h := range c.headers {
+		if i < len(record) {
+			obj[h] = record[i]
+		}
+	}
+
+	part := message.NewPart(nil)
+	if err := part.SetJSON(obj); err != nil {
+		return nil, nil, err
+	}
+
+	return []types.Part{part}, c.pending.Add(), nil
+}
+
+func (c *csvReaderType) Close(ctx context.Context) error {
+	if !c.closed {
+		c.closed = true
+		closeErr := errServiceShuttingDown
+		if c.done {
+			closeErr = nil
+		}
+		_ = c.pending.Done(ctx, closeErr)
+	}
+	return c.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// multipartReader wraps an inner Reader so that each Next call gathers
+// every part up until (but excluding) an empty part, returning them as a
+// single batch. An empty part acts as the separator between batches.
+type multipartReader struct {
+	inner  Reader
+	closed bool
+}
+
+func newMultipartReader(ctor ReaderConstructor, path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+	inner, err := ctor(path, r, ackFn)
+	if err != nil {
+		return nil, err
+	}
+	return &multipartReader{inner: inner}, nil
+}
+
+func (m *multipartReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	var parts []types.Part
+	var ackFns []ReaderAckFn
+
+	for {
+		p, ackFn, err := m.inner.Next(ctx)
+		if err != nil {
+			if err == io.EOF && len(parts) > 0 {
+				break
+			}
+			return nil, nil, err
+		}
+		if len(p) == 1 && len(p[0].Get()) == 0 {
+			_ = ackFn(ctx, nil)
+			if len(parts) > 0 {
+				break
+			}
+			continue
+		}
+		parts = append(parts, p...)
+		ackFns = append(ackFns, ackFn)
+	}
+
+	return parts, combineAcks(ackFns), nil
+}
+
+func combineAcks(ackFns []ReaderAckFn) ReaderAckFn {
+	return func(ctx context.Context, err error) error {
+		var firstErr error
+		for _, fn := range ackFns {
+			if ackErr := fn(ctx, err); ackErr != nil && firstErr == nil {
+				firstErr = ackErr
+			}
+		}
+		return firstErr
+	}
+}
+
+func (m *multipartReader) Close(ctx context.Context) error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.inner.Close(ctx)
+}
+
+//------------------------------------------------------------------------------
+
+var errServiceShuttingDown = fmt.Errorf("service shutting down")
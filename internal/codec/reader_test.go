@@ -2,16 +2,26 @@ package codec
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/types"
+	eszip "github.com/alexmullins/zip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/linkedin/goavro/v2"
+	"github.com/pierrec/lz4"
+	"github.com/segmentio/parquet-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -461,6 +471,55 @@ func TestTarGzipReaderOld(t *testing.T) {
 	testReaderSuite(t, "auto", "foo.tgz", gzipBuf.Bytes(), input...)
 }
 
+func TestTarReaderWithHeaders(t *testing.T) {
+	modTime := time.Date(2023, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	hdr := &tar.Header{
+		Name:     "testfile.txt",
+		Mode:     0o640,
+		Uid:      1000,
+		Gid:      1001,
+		Size:     int64(len("hello")),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+		Uname:    "alice",
+		Gname:    "staff",
+		PAXRecords: map[string]string{
+			"comment": "a PAX record",
+		},
+	}
+	require.NoError(t, tw.WriteHeader(hdr))
+	_, err := tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	ctor, err := GetReader("tar:with_headers", NewReaderConfig())
+	require.NoError(t, err)
+
+	r, err := ctor("", noopCloser{bytes.NewReader(tarBuf.Bytes()), false}, func(context.Context, error) error { return nil })
+	require.NoError(t, err)
+
+	p, ackFn, err := r.Next(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, ackFn(context.Background(), nil))
+	require.Len(t, p, 1)
+
+	assert.Equal(t, "hello", string(p[0].Get()))
+	assert.Equal(t, "testfile.txt", p[0].Metadata().Get("tar_name"))
+	assert.Equal(t, "416", p[0].Metadata().Get("tar_mode"))
+	assert.Equal(t, "1000", p[0].Metadata().Get("tar_uid"))
+	assert.Equal(t, "1001", p[0].Metadata().Get("tar_gid"))
+	assert.Equal(t, "5", p[0].Metadata().Get("tar_size"))
+	assert.Equal(t, modTime.Format(time.RFC3339), p[0].Metadata().Get("tar_mod_time"))
+	assert.Equal(t, "alice", p[0].Metadata().Get("tar_uname"))
+	assert.Equal(t, "staff", p[0].Metadata().Get("tar_gname"))
+	assert.Equal(t, "a PAX record", p[0].Metadata().Get("tar_pax_comment"))
+
+	require.NoError(t, r.Close(context.Background()))
+}
+
 func strsFromParts(ps []types.Part) []string {
 	var strs []string
 	for _, part := range ps {
@@ -671,3 +730,363 @@ func TestMultipartLinesReader(t *testing.T) {
 	data = []byte("")
 	testReaderSuite(t, "lines/multipart", "", data)
 }
+
+func TestRegexReader(t *testing.T) {
+	data := []byte("2023-01-01 foo\n2023-01-02 bar\n2023-01-03 baz")
+	testReaderSuite(
+		t, `regex:\d{4}-\d{2}-\d{2} `, "", data,
+		"2023-01-01 foo\n", "2023-01-02 bar\n", "2023-01-03 baz",
+	)
+
+	data = []byte("")
+	testReaderSuite(t, `regex:\d{4}-\d{2}-\d{2} `, "", data)
+}
+
+func TestNetstringReader(t *testing.T) {
+	data := []byte("3:foo,3:bar,3:baz,")
+	testReaderSuite(t, "netstring", "", data, "foo", "bar", "baz")
+
+	data = []byte("")
+	testReaderSuite(t, "netstring", "", data)
+}
+
+func TestNetstringReaderMaxFrameSize(t *testing.T) {
+	ctor, err := GetReader("netstring:max_frame_size=4", NewReaderConfig())
+	require.NoError(t, err)
+
+	buf := noopCloser{bytes.NewReader([]byte("5:hello,")), false}
+	r, err := ctor("", buf, func(ctx context.Context, err error) error { return nil })
+	require.NoError(t, err)
+
+	_, _, err = r.Next(context.Background())
+	assert.EqualError(t, err, "netstring frame of 5 bytes exceeds max_frame_size of 4 bytes")
+}
+
+func TestLengthPrefixedReader(t *testing.T) {
+	var buf bytes.Buffer
+	for _, s := range []string{"foo", "bar", "baz"} {
+		var lenBuf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(lenBuf[:], uint64(len(s)))
+		buf.Write(lenBuf[:n])
+		buf.WriteString(s)
+	}
+	testReaderSuite(t, "length-prefixed:varint", "", buf.Bytes(), "foo", "bar", "baz")
+
+	buf.Reset()
+	for _, s := range []string{"foo", "bar", "baz"} {
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(s)))
+		buf.Write(lenBuf[:])
+		buf.WriteString(s)
+	}
+	testReaderSuite(t, "length-prefixed:be32", "", buf.Bytes(), "foo", "bar", "baz")
+
+	buf.Reset()
+	for _, s := range []string{"foo", "bar", "baz"} {
+		var lenBuf [4]byte
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(s)))
+		buf.Write(lenBuf[:])
+		buf.WriteString(s)
+	}
+	testReaderSuite(t, "length-prefixed:le32", "", buf.Bytes(), "foo", "bar", "baz")
+
+	data := []byte("")
+	testReaderSuite(t, "length-prefixed:varint", "", data)
+}
+
+func TestLengthPrefixedReaderMaxFrameSize(t *testing.T) {
+	ctor, err := GetReader("length-prefixed:varint,max_frame_size=4", NewReaderConfig())
+	require.NoError(t, err)
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len("hello")))
+	data := append(append([]byte{}, lenBuf[:n]...), "hello"...)
+
+	buf := noopCloser{bytes.NewReader(data), false}
+	r, err := ctor("", buf, func(ctx context.Context, err error) error { return nil })
+	require.NoError(t, err)
+
+	_, _, err = r.Next(context.Background())
+	assert.EqualError(t, err, "length-prefixed frame of 5 bytes exceeds max_frame_size of 4 bytes")
+}
+
+func TestJSONArrayReader(t *testing.T) {
+	data := []byte(`[{"a":1},{"b":[1,2,3]},"foo"]`)
+	testReaderSuite(
+		t, "json-array", "", data,
+		`{"a":1}`, `{"b":[1,2,3]}`, `"foo"`,
+	)
+
+	data = []byte(`[]`)
+	testReaderSuite(t, "json-array", "", data)
+}
+
+func TestJSONLSchemaReader(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, ioutil.WriteFile(schemaPath, []byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {"name": {"type": "string"}}
+	}`), 0o644))
+
+	data := []byte("{\"name\":\"foo\"}\nnot json\n{\"name\":123}\n{\"name\":\"bar\"}")
+	testReaderSuite(
+		t, "jsonl-schema:file://"+schemaPath, "", data,
+		`{"name":"foo"}`, "not json", `{"name":123}`, `{"name":"bar"}`,
+	)
+}
+
+func TestJSONLSchemaReaderFlagsInvalidLines(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, ioutil.WriteFile(schemaPath, []byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {"name": {"type": "string"}}
+	}`), 0o644))
+
+	data := []byte("{\"name\":\"foo\"}\nnot json\n{\"name\":123}\n{\"name\":\"bar\"}")
+
+	ctor, err := GetReader("jsonl-schema:file://"+schemaPath, NewReaderConfig())
+	require.NoError(t, err)
+
+	ack := errors.New("default err")
+	r, err := ctor("", noopCloser{bytes.NewReader(data), false}, func(ctx context.Context, err error) error {
+		ack = err
+		return nil
+	})
+	require.NoError(t, err)
+
+	var flagged []string
+	for i := 0; i < 4; i++ {
+		p, ackFn, err := r.Next(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, ackFn(context.Background(), nil))
+		if p[0].Metadata().Get(jsonlSchemaValidationErrorMetaKey) != "" {
+			flagged = append(flagged, string(p[0].Get()))
+		}
+	}
+
+	_, _, err = r.Next(context.Background())
+	assert.EqualError(t, err, "EOF")
+	require.NoError(t, r.Close(context.Background()))
+
+	assert.Equal(t, []string{"not json", `{"name":123}`}, flagged)
+	assert.NoError(t, ack)
+}
+
+func TestZipReader(t *testing.T) {
+	input := []string{
+		"first document",
+		"second document",
+		"third document",
+	}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for i, body := range input {
+		w, err := zw.Create(fmt.Sprintf("testfile%v.txt", i))
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zip", "", zipBuf.Bytes(), input...)
+	testReaderSuite(t, "auto", "foo.zip", zipBuf.Bytes(), input...)
+}
+
+func TestZipReaderGlob(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+
+	w, err := zw.Create("keep.log")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("keep me"))
+	require.NoError(t, err)
+
+	w, err = zw.Create("skip.json")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("skip me"))
+	require.NoError(t, err)
+
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zip:glob=*.log", "", zipBuf.Bytes(), "keep me")
+}
+
+func TestZipReaderPassword(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := eszip.NewWriter(&zipBuf)
+
+	w, err := zw.Encrypt("secret.txt", "hunter2")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("classified"))
+	require.NoError(t, err)
+
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zip:password=hunter2", "", zipBuf.Bytes(), "classified")
+}
+
+func TestZstdLinesReader(t *testing.T) {
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("foo\nbar\nbaz"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zstd/lines", "", zstdBuf.Bytes(), "foo", "bar", "baz")
+	testReaderSuite(t, "auto", "foo.zst", zstdBuf.Bytes(), "foo", "bar", "baz")
+	testReaderSuite(t, "auto", "foo.zstd", zstdBuf.Bytes(), "foo", "bar", "baz")
+}
+
+func TestZstdTarReader(t *testing.T) {
+	input := []string{
+		"first document",
+		"second document",
+	}
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for i := range input {
+		hdr := &tar.Header{
+			Name: fmt.Sprintf("testfile%v", i),
+			Mode: 0o600,
+			Size: int64(len(input[i])),
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(input[i]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	require.NoError(t, err)
+	_, err = zw.Write(tarBuf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zstd/tar", "", zstdBuf.Bytes(), input...)
+	testReaderSuite(t, "auto", "foo.tar.zst", zstdBuf.Bytes(), input...)
+	testReaderSuite(t, "auto", "foo.tzst", zstdBuf.Bytes(), input...)
+}
+
+func TestLZ4LinesReader(t *testing.T) {
+	var lz4Buf bytes.Buffer
+	zw := lz4.NewWriter(&lz4Buf)
+	_, err := zw.Write([]byte("foo\nbar\nbaz"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "lz4/lines", "", lz4Buf.Bytes(), "foo", "bar", "baz")
+	testReaderSuite(t, "auto", "foo.lz4", lz4Buf.Bytes(), "foo", "bar", "baz")
+}
+
+func TestZipLinesReader(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+
+	w, err := zw.Create("entry.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("foo\nbar\nbaz"))
+	require.NoError(t, err)
+
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, "zip/lines", "", zipBuf.Bytes(), "foo", "bar", "baz")
+}
+
+func TestZipNetstringInnerErrorSkipsToNextEntry(t *testing.T) {
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+
+	w, err := zw.Create("bad.ns")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("not a netstring"))
+	require.NoError(t, err)
+
+	w, err = zw.Create("good.ns")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("3:foo,"))
+	require.NoError(t, err)
+
+	require.NoError(t, zw.Close())
+
+	ctor, err := GetReader("zip/netstring", NewReaderConfig())
+	require.NoError(t, err)
+
+	r, err := ctor("", noopCloser{bytes.NewReader(zipBuf.Bytes()), false}, func(ctx context.Context, err error) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, _, err = r.Next(context.Background())
+	require.Error(t, err)
+
+	p, _, err := r.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(p[0].Get()))
+
+	_, _, err = r.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+
+	assert.NoError(t, r.Close(context.Background()))
+}
+
+func TestParquetReader(t *testing.T) {
+	type testRow struct {
+		Col1 string `parquet:"col1"`
+		Col2 int64  `parquet:"col2"`
+	}
+
+	rows := []testRow{
+		{Col1: "foo1", Col2: 1},
+		{Col1: "foo2", Col2: 2},
+	}
+
+	var parquetBuf bytes.Buffer
+	require.NoError(t, parquet.Write(&parquetBuf, rows))
+
+	testReaderSuite(
+		t, "parquet", "", parquetBuf.Bytes(),
+		`{"col1":"foo1","col2":1}`,
+		`{"col1":"foo2","col2":2}`,
+	)
+	testReaderSuite(
+		t, "auto", "foo.parquet", parquetBuf.Bytes(),
+		`{"col1":"foo1","col2":1}`,
+		`{"col1":"foo2","col2":2}`,
+	)
+}
+
+func TestAvroOCFReader(t *testing.T) {
+	schema := `{
+		"type": "record",
+		"name": "testRow",
+		"fields": [
+			{"name": "col1", "type": "string"},
+			{"name": "col2", "type": "long"}
+		]
+	}`
+
+	var avroBuf bytes.Buffer
+	w, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &avroBuf, Schema: schema})
+	require.NoError(t, err)
+	require.NoError(t, w.Append([]map[string]interface{}{
+		{"col1": "foo1", "col2": int64(1)},
+		{"col1": "foo2", "col2": int64(2)},
+	}))
+
+	testReaderSuite(
+		t, "avro-ocf", "", avroBuf.Bytes(),
+		`{"col1":"foo1","col2":1}`,
+		`{"col1":"foo2","col2":2}`,
+	)
+	testReaderSuite(
+		t, "auto", "foo.avro", avroBuf.Bytes(),
+		`{"col1":"foo1","col2":1}`,
+		`{"col1":"foo2","col2":2}`,
+	)
+}
@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// tarReaderCtor returns a tar codec constructor. When withHeaders is true,
+// each emitted part also carries its tar header fields as metadata (e.g.
+// tar_name, tar_mod_time), enabling downstream routing on a per-entry basis
+// without a follow-up processor.
+func tarReaderCtor(withHeaders bool) ReaderConstructor {
+	return func(path string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		return &tarReaderType{
+			r:           r,
+			tr:          tar.NewReader(r),
+			withHeaders: withHeaders,
+			pending:     newPendingAcks(ackFn),
+		}, nil
+	}
+}
+
+type tarReaderType struct {
+	r           io.ReadCloser
+	tr          *tar.Reader
+	withHeaders bool
+	pending     *pendingAcks
+	closed      bool
+	reachedEOF  bool
+}
+
+func (t *tarReaderType) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	hdr, err := t.tr.Next()
+	if err != nil {
+		t.reachedEOF = true
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(t.tr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	part := message.NewPart(data)
+	if t.withHeaders {
+		setTarHeaderMetadata(part, hdr)
+	}
+
+	return []types.Part{part}, t.pending.Add(), nil
+}
+
+func setTarHeaderMetadata(part types.Part, hdr *tar.Header) {
+	meta := part.Metadata()
+	meta.Set("tar_name", hdr.Name)
+	meta.Set("tar_mode", strconv.FormatInt(hdr.Mode, 10))
+	meta.Set("tar_uid", strconv.Itoa(hdr.Uid))
+	meta.Set("tar_gid", strconv.Itoa(hdr.Gid))
+	meta.Set("tar_size", strconv.FormatInt(hdr.Size, 10))
+	meta.Set("tar_mod_time", hdr.ModTime.UTC().Format(time.RFC3339))
+	meta.Set("tar_typeflag", string(hdr.Typeflag))
+	meta.Set("tar_uname", hdr.Uname)
+	meta.Set("tar_gname", hdr.Gname)
+	meta.Set("tar_linkname", hdr.Linkname)
+	for k, v := range hdr.PAXRecords {
+		meta.Set("tar_pax_"+k, v)
+	}
+}
+
+func (t *tarReaderType) Close(ctx context.Context) error {
+	if !t.closed {
+		t.closed = true
+		closeErr := errServiceShuttingDown
+		if t.reachedEOF {
+			closeErr = nil
+		}
+		_ = t.pending.Done(ctx, closeErr)
+	}
+	return t.r.Close()
+}
@@ -0,0 +1,197 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/alexmullins/zip"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+// maxZipBufferBytes is the largest zip stream that will be buffered fully in
+// memory before spilling over to a temporary file. Zip central directories
+// live at the end of the archive, so the whole stream must be available
+// through an io.ReaderAt before any entry can be opened.
+const maxZipBufferBytes = 64 * 1024 * 1024
+
+// zipCodec parses a "zip[:opt=val,...][/inner]" codec string into its
+// filter/password options and an optional nested codec used to further
+// parse each entry.
+func zipCodec(conf ReaderConfig, codec string) (ReaderConstructor, error) {
+	rest := strings.TrimPrefix(codec, "zip")
+
+	var optsStr, inner string
+	switch {
+	case strings.HasPrefix(rest, ":"):
+		rest = rest[1:]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			optsStr, inner = rest[:i], rest[i+1:]
+		} else {
+			optsStr = rest
+		}
+	case strings.HasPrefix(rest, "/"):
+		inner = rest[1:]
+	case rest != "":
+		return nil, fmt.Errorf("codec was not recognised: zip%v", rest)
+	}
+
+	var glob, password string
+	for _, opt := range strings.Split(optsStr, ",") {
+		if opt == "" {
+			continue
+		}
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid zip codec option: %v", opt)
+		}
+		switch kv[0] {
+		case "glob":
+			glob = kv[1]
+		case "password":
+			password = kv[1]
+		default:
+			return nil, fmt.Errorf("unrecognised zip codec option: %v", kv[0])
+		}
+	}
+
+	var innerCtor ReaderConstructor
+	if inner != "" {
+		var err error
+		if innerCtor, err = getReader(inner, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(epath string, r io.ReadCloser, ackFn ReaderAckFn) (Reader, error) {
+		ra, size, cleanup, err := bufferToReaderAt(r, maxZipBufferBytes)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			cleanup()
+			r.Close()
+			return nil, err
+		}
+
+		var entries []*zip.File
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if glob != "" {
+				if ok, _ := path.Match(glob, f.Name); !ok {
+					continue
+				}
+			}
+			entries = append(entries, f)
+		}
+
+		return &zipReader{
+			r:         r,
+			cleanup:   cleanup,
+			entries:   entries,
+			password:  password,
+			innerCtor: innerCtor,
+			pending:   newPendingAcks(ackFn),
+		}, nil
+	}, nil
+}
+
+// zipReader iterates the entries of a zip archive, emitting either the raw
+// entry body as a single part (the default) or, when an inner codec was
+// given, delegating each entry's stream to that codec so it can be further
+// split (e.g. "zip/lines").
+type zipReader struct {
+	r          io.ReadCloser
+	cleanup    func() error
+	entries    []*zip.File
+	idx        int
+	password   string
+	innerCtor  ReaderConstructor
+	pending    *pendingAcks
+	current    Reader
+	closed     bool
+	reachedEOF bool
+}
+
+func (z *zipReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	for {
+		if z.current != nil {
+			p, ackFn, err := z.current.Next(ctx)
+			if err == nil {
+				return p, ackFn, nil
+			}
+			// Any error is terminal for the inner reader, so close it and
+			// stop pulling from this entry. io.EOF falls through to open the
+			// next entry; any other error is returned first, and the next
+			// call opens the next entry rather than abandoning the archive.
+			_ = z.current.Close(ctx)
+			z.current = nil
+			if err != io.EOF {
+				return nil, nil, err
+			}
+		}
+
+		if z.idx >= len(z.entries) {
+			z.reachedEOF = true
+			return nil, nil, io.EOF
+		}
+
+		f := z.entries[z.idx]
+		z.idx++
+
+		if z.password != "" {
+			f.SetPassword(z.password)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if z.innerCtor == nil {
+			data, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			part := message.NewPart(data)
+			return []types.Part{part}, z.pending.Add(), nil
+		}
+
+		inner, err := z.innerCtor(f.Name, rc, z.pending.Add())
+		if err != nil {
+			rc.Close()
+			return nil, nil, err
+		}
+		z.current = inner
+	}
+}
+
+func (z *zipReader) Close(ctx context.Context) error {
+	if !z.closed {
+		z.closed = true
+		if z.current != nil {
+			_ = z.current.Close(ctx)
+		}
+		closeErr := errServiceShuttingDown
+		if z.reachedEOF {
+			closeErr = nil
+		}
+		_ = z.pending.Done(ctx, closeErr)
+	}
+	rErr := z.r.Close()
+	if cErr := z.cleanup(); cErr != nil && rErr == nil {
+		rErr = cErr
+	}
+	return rErr
+}